@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const userIDContextKey contextKey = iota
+
+// AuthMiddleware checks the Authorization: Bearer <token> header against the
+// shared secrets in cfg and, on success, attaches the resolved user ID to
+// the request context so handlers operate on that user's session instead
+// of a single global client.
+func AuthMiddleware(cfg *Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			token := strings.TrimPrefix(authHeader, "Bearer ")
+			if token == "" || token == authHeader {
+				respondJSONError(w, http.StatusUnauthorized, "missing bearer token")
+				return
+			}
+
+			userID, ok := cfg.UserIDForToken(token)
+			if !ok {
+				respondJSONError(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDContextKey, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UserIDFromContext returns the user ID attached by AuthMiddleware.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDContextKey).(string)
+	return userID, ok
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is loaded once at startup from a JSON file and holds the
+// provisioning secrets for every WhatsApp account the bridge manages.
+// Each entry in Users maps a user ID (chosen by the operator) to the
+// bearer token that authenticates requests acting on that user's session.
+type Config struct {
+	Port               string            `json:"port"`
+	ProvisioningPrefix string            `json:"provisioning_prefix"`
+	Users              map[string]string `json:"users"`
+	Webhooks           []WebhookConfig   `json:"webhooks"`
+}
+
+// WebhookConfig describes one outbound subscriber that wants to be
+// notified of inbound WhatsApp events.
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"` // empty means every event type
+}
+
+// LoadConfig reads and validates the config file at path, filling in
+// defaults for any fields the operator left blank.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+
+	if cfg.ProvisioningPrefix == "" {
+		cfg.ProvisioningPrefix = "/_provision/v1"
+	}
+	if cfg.Port == "" {
+		cfg.Port = "3000"
+	}
+	if len(cfg.Users) == 0 {
+		return nil, fmt.Errorf("config must declare at least one user")
+	}
+
+	return cfg, nil
+}
+
+// UserIDForToken returns the user ID whose shared secret matches token.
+// The comparison is constant-time so a caller can't use response timing
+// to guess a valid secret.
+func (c *Config) UserIDForToken(token string) (string, bool) {
+	for userID, secret := range c.Users {
+		if secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+			return userID, true
+		}
+	}
+	return "", false
+}
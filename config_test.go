@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestUserIDForToken(t *testing.T) {
+	cfg := &Config{Users: map[string]string{
+		"alice": "secret-a",
+		"bob":   "secret-b",
+		"carol": "",
+	}}
+
+	tests := []struct {
+		token      string
+		wantUserID string
+		wantOK     bool
+	}{
+		{"secret-a", "alice", true},
+		{"secret-b", "bob", true},
+		{"wrong", "", false},
+		{"", "", false},
+		{"", "", false}, // carol has no secret, so an empty token must never match
+	}
+
+	for _, tt := range tests {
+		userID, ok := cfg.UserIDForToken(tt.token)
+		if userID != tt.wantUserID || ok != tt.wantOK {
+			t.Errorf("UserIDForToken(%q) = (%q, %v), want (%q, %v)", tt.token, userID, ok, tt.wantUserID, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleEvent is the whatsmeow event handler shared by every user's
+// client. It tags log lines with the owning user ID, records new JIDs in
+// SessionStore on a successful pairing, and forwards matching events to
+// the webhook dispatcher instead of the bot replying on its own.
+func (um *UserManager) handleEvent(userID string, client *whatsmeow.Client, evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Message:
+		text, mediaType := extractMessageContent(v.Message)
+
+		addLog(fmt.Sprintf("[%s] Message from %s in %s", userID, v.Info.Sender.String(), v.Info.Chat.String()))
+		stored := StoredMessage{
+			ID:        v.Info.ID,
+			ChatJID:   v.Info.Chat.String(),
+			SenderJID: v.Info.Sender.String(),
+			FromMe:    v.Info.IsFromMe,
+			Text:      text,
+			MediaType: mediaType,
+			Timestamp: v.Info.Timestamp.Unix(),
+		}
+		if um.history != nil {
+			if err := um.history.InsertMessage(userID, stored); err != nil {
+				addLog(fmt.Sprintf("[%s] Failed to store message: %s", userID, err.Error()))
+			} else {
+				um.storeMediaRef(userID, stored.ID, v.Message)
+			}
+		}
+		um.dispatchWebhook(userID, "message", stored)
+	case *events.Receipt:
+		um.dispatchWebhook(userID, "receipt", receiptEventData{
+			ChatJID:    v.Chat.String(),
+			SenderJID:  v.Sender.String(),
+			MessageIDs: v.MessageIDs,
+			Type:       string(v.Type),
+			Timestamp:  v.Timestamp.Unix(),
+		})
+	case *events.Presence:
+		um.dispatchWebhook(userID, "presence", presenceEventData{
+			JID:         v.From.String(),
+			Unavailable: v.Unavailable,
+			LastSeen:    v.LastSeen.Unix(),
+		})
+	case *events.HistorySync:
+		addLog(fmt.Sprintf("[%s] Received history sync", userID))
+		if um.history != nil {
+			go um.ingestHistorySync(userID, v)
+		}
+		um.dispatchWebhook(userID, "history_sync", historySyncEventData{
+			SyncType: v.Data.SyncType.String(),
+		})
+	case *events.PairSuccess:
+		addLog(fmt.Sprintf("[%s] Paired as %s", userID, v.ID.String()))
+		um.sessions.Set(userID, v.ID.String())
+	case *events.Connected:
+		addLog(fmt.Sprintf("[%s] Connected to WhatsApp!", userID))
+		um.states.set(userID, StateConnected, "", "")
+		um.dispatchWebhook(userID, "connected", nil)
+	case *events.Disconnected:
+		addLog(fmt.Sprintf("[%s] Disconnected from WhatsApp", userID))
+		um.states.set(userID, StateTransientDisconnect, "websocket disconnected", "")
+		um.dispatchWebhook(userID, "disconnected", nil)
+	case *events.LoggedOut:
+		addLog(fmt.Sprintf("[%s] Logged out from WhatsApp: %v", userID, v.Reason))
+		um.states.set(userID, StateLoggedOut, fmt.Sprintf("%v", v.Reason), fmt.Sprintf("%d", v.Reason.NumberCode()))
+		um.dispatchWebhook(userID, "disconnected", nil)
+	case *events.StreamReplaced:
+		addLog(fmt.Sprintf("[%s] Stream replaced by another session", userID))
+		um.states.set(userID, StateTransientDisconnect, "stream replaced by another session", "")
+		um.dispatchWebhook(userID, "disconnected", nil)
+	case *events.ConnectFailure:
+		addLog(fmt.Sprintf("[%s] Connect failure: %v", userID, v.Reason))
+		um.states.set(userID, StateUnknownError, fmt.Sprintf("%v", v.Reason), fmt.Sprintf("%d", v.Reason.NumberCode()))
+		um.dispatchWebhook(userID, "disconnected", nil)
+	case *events.TemporaryBan:
+		addLog(fmt.Sprintf("[%s] Temporarily banned: %v", userID, v.Code))
+		um.states.set(userID, StateBadCredentials, fmt.Sprintf("%v", v.Code), fmt.Sprintf("%d", v.Code.NumberCode()))
+		um.dispatchWebhook(userID, "disconnected", nil)
+	}
+}
+
+type receiptEventData struct {
+	ChatJID    string   `json:"chat_jid"`
+	SenderJID  string   `json:"sender_jid"`
+	MessageIDs []string `json:"message_ids"`
+	Type       string   `json:"receipt_type"`
+	Timestamp  int64    `json:"timestamp"`
+}
+
+type presenceEventData struct {
+	JID         string `json:"jid"`
+	Unavailable bool   `json:"unavailable"`
+	LastSeen    int64  `json:"last_seen,omitempty"`
+}
+
+type historySyncEventData struct {
+	SyncType string `json:"sync_type"`
+}
+
+// dispatchWebhook serializes data and hands it to the webhook dispatcher,
+// if one is configured. data may be nil for events with no extra payload.
+func (um *UserManager) dispatchWebhook(userID, eventType string, data interface{}) {
+	if um.webhooks == nil {
+		return
+	}
+
+	var raw json.RawMessage
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			addLog(fmt.Sprintf("[%s] Failed to marshal %s event: %s", userID, eventType, err.Error()))
+			return
+		}
+		raw = encoded
+	}
+
+	um.webhooks.Dispatch(WebhookEvent{
+		Type:      eventType,
+		UserID:    userID,
+		Timestamp: time.Now().Unix(),
+		Data:      raw,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// registerHistoryRoutes wires the read-only archive endpoints backed by
+// HistoryStore: listing chats, paginating a chat's messages, and looking
+// up a single message by ID.
+func registerHistoryRoutes(r *mux.Router, cfg *Config, history *HistoryStore) {
+	auth := AuthMiddleware(cfg)
+
+	r.Handle("/chats", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		chats, err := history.ListChats(userID)
+		if err != nil {
+			respondJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{"chats": chats})
+	}))).Methods("GET")
+
+	r.Handle("/chats/{jid}/messages", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		chatJID := mux.Vars(r)["jid"]
+
+		var before int64
+		if raw := r.URL.Query().Get("before"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				respondJSONError(w, http.StatusBadRequest, "before must be a unix timestamp")
+				return
+			}
+			before = parsed
+		}
+
+		limit := 50
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil {
+				respondJSONError(w, http.StatusBadRequest, "limit must be an integer")
+				return
+			}
+			limit = parsed
+		}
+
+		messages, err := history.ListMessages(userID, chatJID, before, limit)
+		if err != nil {
+			respondJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{"messages": messages})
+	}))).Methods("GET")
+
+	r.Handle("/messages/{id}", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		id := mux.Vars(r)["id"]
+
+		msg, err := history.GetMessage(userID, id)
+		if err != nil {
+			respondJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if msg == nil {
+			respondJSONError(w, http.StatusNotFound, "message not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, msg)
+	}))).Methods("GET")
+}
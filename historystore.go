@@ -0,0 +1,221 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// StoredChat is one row of the chats table: a conversation the bridge has
+// seen, either from history sync or from live traffic.
+type StoredChat struct {
+	JID             string `json:"jid"`
+	Name            string `json:"name,omitempty"`
+	LastMessageTime int64  `json:"last_message_time,omitempty"`
+}
+
+// StoredMessage is one row of the messages table.
+type StoredMessage struct {
+	ID        string `json:"id"`
+	ChatJID   string `json:"chat_jid"`
+	SenderJID string `json:"sender_jid,omitempty"`
+	FromMe    bool   `json:"from_me"`
+	Text      string `json:"text,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// StoredMediaRef is one row of the media_refs table: just enough of an
+// attachment's upload metadata to re-download it later.
+type StoredMediaRef struct {
+	MessageID  string `json:"message_id"`
+	MimeType   string `json:"mime_type,omitempty"`
+	DirectPath string `json:"direct_path,omitempty"`
+	MediaKey   []byte `json:"-"`
+	FileSHA256 []byte `json:"-"`
+	FileLength uint64 `json:"file_length,omitempty"`
+}
+
+// HistoryStore persists chats, messages, and media references decoded
+// from history sync payloads (and from incremental live traffic) into a
+// dedicated sqlite schema, so the bridge can answer queries about
+// messages it isn't currently holding in memory.
+type HistoryStore struct {
+	db *sql.DB
+}
+
+// NewHistoryStore opens (creating if necessary) the sqlite database at
+// dbPath and ensures its schema exists.
+func NewHistoryStore(dbPath string) (*HistoryStore, error) {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("opening history store: %w", err)
+	}
+	// History sync ingestion and live message handling both write from
+	// per-user goroutines; serialize on a single connection so sqlite's busy
+	// timeout absorbs contention instead of a second connection racing for
+	// the lock and returning SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS chats (
+			user_id TEXT NOT NULL,
+			jid TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			last_message_time INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, jid)
+		)`,
+		`CREATE TABLE IF NOT EXISTS messages (
+			user_id TEXT NOT NULL,
+			id TEXT NOT NULL,
+			chat_jid TEXT NOT NULL,
+			sender_jid TEXT NOT NULL DEFAULT '',
+			from_me INTEGER NOT NULL DEFAULT 0,
+			text TEXT NOT NULL DEFAULT '',
+			media_type TEXT NOT NULL DEFAULT '',
+			timestamp INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_chat ON messages (user_id, chat_jid, timestamp)`,
+		`CREATE TABLE IF NOT EXISTS media_refs (
+			user_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			mime_type TEXT NOT NULL DEFAULT '',
+			direct_path TEXT NOT NULL DEFAULT '',
+			media_key BLOB,
+			file_sha256 BLOB,
+			file_length INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, message_id)
+		)`,
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("creating history schema: %w", err)
+		}
+	}
+
+	return &HistoryStore{db: db}, nil
+}
+
+// UpsertChat records chat, keeping the existing name if the new one is
+// blank and the latest of the two last-message timestamps.
+func (h *HistoryStore) UpsertChat(userID string, chat StoredChat) error {
+	_, err := h.db.Exec(`
+		INSERT INTO chats (user_id, jid, name, last_message_time) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, jid) DO UPDATE SET
+			name = CASE WHEN excluded.name != '' THEN excluded.name ELSE chats.name END,
+			last_message_time = MAX(chats.last_message_time, excluded.last_message_time)`,
+		userID, chat.JID, chat.Name, chat.LastMessageTime)
+	if err != nil {
+		return fmt.Errorf("upserting chat: %w", err)
+	}
+	return nil
+}
+
+// InsertMessage stores msg and bumps its chat's last_message_time.
+// Re-inserting the same (user, id) pair overwrites the existing row, so
+// history sync and live traffic can safely race on the same message.
+func (h *HistoryStore) InsertMessage(userID string, msg StoredMessage) error {
+	_, err := h.db.Exec(`
+		INSERT OR REPLACE INTO messages
+			(user_id, id, chat_jid, sender_jid, from_me, text, media_type, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, msg.ID, msg.ChatJID, msg.SenderJID, msg.FromMe, msg.Text, msg.MediaType, msg.Timestamp)
+	if err != nil {
+		return fmt.Errorf("inserting message: %w", err)
+	}
+	return h.UpsertChat(userID, StoredChat{JID: msg.ChatJID, LastMessageTime: msg.Timestamp})
+}
+
+// InsertMediaRef stores the upload metadata needed to re-download a
+// message's attachment.
+func (h *HistoryStore) InsertMediaRef(userID string, ref StoredMediaRef) error {
+	_, err := h.db.Exec(`
+		INSERT OR REPLACE INTO media_refs
+			(user_id, message_id, mime_type, direct_path, media_key, file_sha256, file_length)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, ref.MessageID, ref.MimeType, ref.DirectPath, ref.MediaKey, ref.FileSHA256, ref.FileLength)
+	if err != nil {
+		return fmt.Errorf("inserting media ref: %w", err)
+	}
+	return nil
+}
+
+// ListChats returns userID's known chats, most recently active first.
+func (h *HistoryStore) ListChats(userID string) ([]StoredChat, error) {
+	rows, err := h.db.Query(`
+		SELECT jid, name, last_message_time FROM chats
+		WHERE user_id = ? ORDER BY last_message_time DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing chats: %w", err)
+	}
+	defer rows.Close()
+
+	var chats []StoredChat
+	for rows.Next() {
+		var c StoredChat
+		if err := rows.Scan(&c.JID, &c.Name, &c.LastMessageTime); err != nil {
+			return nil, fmt.Errorf("scanning chat: %w", err)
+		}
+		chats = append(chats, c)
+	}
+	return chats, rows.Err()
+}
+
+// ListMessages returns up to limit messages in chatJID older than before
+// (a unix timestamp), most recent first. before <= 0 means "no bound".
+func (h *HistoryStore) ListMessages(userID, chatJID string, before int64, limit int) ([]StoredMessage, error) {
+	switch {
+	case limit <= 0:
+		limit = 50
+	case limit > 200:
+		limit = 200
+	}
+
+	query := `
+		SELECT id, chat_jid, sender_jid, from_me, text, media_type, timestamp
+		FROM messages WHERE user_id = ? AND chat_jid = ?`
+	args := []interface{}{userID, chatJID}
+	if before > 0 {
+		query += ` AND timestamp < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY timestamp DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []StoredMessage
+	for rows.Next() {
+		var m StoredMessage
+		var fromMe int
+		if err := rows.Scan(&m.ID, &m.ChatJID, &m.SenderJID, &fromMe, &m.Text, &m.MediaType, &m.Timestamp); err != nil {
+			return nil, fmt.Errorf("scanning message: %w", err)
+		}
+		m.FromMe = fromMe != 0
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// GetMessage returns a single message by ID, or nil if it isn't known.
+func (h *HistoryStore) GetMessage(userID, id string) (*StoredMessage, error) {
+	row := h.db.QueryRow(`
+		SELECT id, chat_jid, sender_jid, from_me, text, media_type, timestamp
+		FROM messages WHERE user_id = ? AND id = ?`, userID, id)
+
+	var m StoredMessage
+	var fromMe int
+	err := row.Scan(&m.ID, &m.ChatJID, &m.SenderJID, &fromMe, &m.Text, &m.MediaType, &m.Timestamp)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting message: %w", err)
+	}
+	m.FromMe = fromMe != 0
+	return &m, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newTestHistoryStore opens an in-memory HistoryStore for a single test.
+// A fresh in-memory database avoids depending on filesystem state or
+// tests trampling each other.
+func newTestHistoryStore(t *testing.T) *HistoryStore {
+	t.Helper()
+	store, err := NewHistoryStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewHistoryStore: %v", err)
+	}
+	return store
+}
+
+func TestListMessagesPagination(t *testing.T) {
+	store := newTestHistoryStore(t)
+
+	const userID, chatJID = "alice", "123@s.whatsapp.net"
+	for i := int64(1); i <= 250; i++ {
+		msg := StoredMessage{ID: fmt.Sprintf("msg%d", i), ChatJID: chatJID, Timestamp: i}
+		if err := store.InsertMessage(userID, msg); err != nil {
+			t.Fatalf("InsertMessage(%d): %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantCount int
+	}{
+		{"unset defaults to 50", 0, 50},
+		{"negative defaults to 50", -5, 50},
+		{"within bounds is respected", 10, 10},
+		{"at the max is respected", 200, 200},
+		{"over the max clamps to 200, not the default", 300, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := store.ListMessages(userID, chatJID, 0, tt.limit)
+			if err != nil {
+				t.Fatalf("ListMessages: %v", err)
+			}
+			if len(messages) != tt.wantCount {
+				t.Errorf("ListMessages(limit=%d) returned %d messages, want %d", tt.limit, len(messages), tt.wantCount)
+			}
+		})
+	}
+}
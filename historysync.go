@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// extractMessageContent pulls the plain-text body and, failing that, the
+// attachment type out of a decoded WhatsApp message. It's shared by the
+// live *events.Message handler and the history sync decoder below, since
+// both walk the same waProto.Message shape.
+func extractMessageContent(msg *waProto.Message) (text, mediaType string) {
+	switch {
+	case msg == nil:
+		return "", ""
+	case msg.Conversation != nil:
+		return *msg.Conversation, ""
+	case msg.ExtendedTextMessage != nil && msg.ExtendedTextMessage.Text != nil:
+		return *msg.ExtendedTextMessage.Text, ""
+	case msg.ImageMessage != nil:
+		return msg.ImageMessage.GetCaption(), "image"
+	case msg.VideoMessage != nil:
+		return msg.VideoMessage.GetCaption(), "video"
+	case msg.AudioMessage != nil:
+		return "", "audio"
+	case msg.DocumentMessage != nil:
+		return msg.DocumentMessage.GetCaption(), "document"
+	default:
+		return "", ""
+	}
+}
+
+// extractMediaRef pulls the upload metadata needed to re-download an
+// attachment out of a decoded WhatsApp message, or nil if it has none.
+func extractMediaRef(msg *waProto.Message) *StoredMediaRef {
+	switch {
+	case msg == nil:
+		return nil
+	case msg.ImageMessage != nil:
+		m := msg.ImageMessage
+		return &StoredMediaRef{MimeType: m.GetMimetype(), DirectPath: m.GetDirectPath(), MediaKey: m.GetMediaKey(), FileSHA256: m.GetFileSha256(), FileLength: m.GetFileLength()}
+	case msg.VideoMessage != nil:
+		m := msg.VideoMessage
+		return &StoredMediaRef{MimeType: m.GetMimetype(), DirectPath: m.GetDirectPath(), MediaKey: m.GetMediaKey(), FileSHA256: m.GetFileSha256(), FileLength: m.GetFileLength()}
+	case msg.AudioMessage != nil:
+		m := msg.AudioMessage
+		return &StoredMediaRef{MimeType: m.GetMimetype(), DirectPath: m.GetDirectPath(), MediaKey: m.GetMediaKey(), FileSHA256: m.GetFileSha256(), FileLength: m.GetFileLength()}
+	case msg.DocumentMessage != nil:
+		m := msg.DocumentMessage
+		return &StoredMediaRef{MimeType: m.GetMimetype(), DirectPath: m.GetDirectPath(), MediaKey: m.GetMediaKey(), FileSHA256: m.GetFileSha256(), FileLength: m.GetFileLength()}
+	default:
+		return nil
+	}
+}
+
+// storeMediaRef saves ref for messageID, if the message carried media.
+func (um *UserManager) storeMediaRef(userID, messageID string, msg *waProto.Message) {
+	ref := extractMediaRef(msg)
+	if ref == nil {
+		return
+	}
+	ref.MessageID = messageID
+	if err := um.history.InsertMediaRef(userID, *ref); err != nil {
+		addLog(fmt.Sprintf("[%s] Failed to store media ref for %s: %s", userID, messageID, err.Error()))
+	}
+}
+
+// ingestHistorySync decodes a history sync payload's conversations and
+// messages into the history store. It runs in its own goroutine per
+// payload so it never blocks the shared whatsmeow event dispatch loop.
+func (um *UserManager) ingestHistorySync(userID string, evt *events.HistorySync) {
+	if evt.Data == nil {
+		return
+	}
+
+	for _, conv := range evt.Data.GetConversations() {
+		chatJID := conv.GetId()
+		if chatJID == "" {
+			continue
+		}
+
+		if err := um.history.UpsertChat(userID, StoredChat{JID: chatJID, Name: conv.GetName()}); err != nil {
+			addLog(fmt.Sprintf("[%s] Failed to store synced chat %s: %s", userID, chatJID, err.Error()))
+			continue
+		}
+
+		for _, historyMsg := range conv.GetMessages() {
+			webMsg := historyMsg.GetMessage()
+			if webMsg == nil || webMsg.GetKey() == nil {
+				continue
+			}
+
+			text, mediaType := extractMessageContent(webMsg.GetMessage())
+			senderJID := webMsg.GetKey().GetParticipant()
+			if senderJID == "" {
+				senderJID = webMsg.GetKey().GetRemoteJid()
+			}
+
+			stored := StoredMessage{
+				ID:        webMsg.GetKey().GetId(),
+				ChatJID:   chatJID,
+				SenderJID: senderJID,
+				FromMe:    webMsg.GetKey().GetFromMe(),
+				Text:      text,
+				MediaType: mediaType,
+				Timestamp: int64(webMsg.GetMessageTimestamp()),
+			}
+			if stored.ID == "" {
+				continue
+			}
+			if err := um.history.InsertMessage(userID, stored); err != nil {
+				addLog(fmt.Sprintf("[%s] Failed to store synced message %s: %s", userID, stored.ID, err.Error()))
+				continue
+			}
+			um.storeMediaRef(userID, stored.ID, webMsg.GetMessage())
+		}
+	}
+}
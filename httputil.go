@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// parseJSONBody decodes the request body into v, returning a descriptive
+// error if the body is missing or malformed.
+func parseJSONBody(r *http.Request, v interface{}) error {
+	if r.Body == nil {
+		return errors.New("request body is empty")
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// respondJSON writes v as a JSON response body with the given status code.
+func respondJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// respondJSONError writes a {"error": message} JSON body with the given
+// status code.
+func respondJSONError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
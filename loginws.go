@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+var loginWSUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// loginWSFrame is the JSON shape streamed to the caller over /login/ws.
+type loginWSFrame struct {
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+	Image   string `json:"image,omitempty"`
+	JID     string `json:"jid,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// loginWSCommand is the first frame the caller sends on /login/ws to pick
+// a pairing method: {"method":"qr"} (the default) or
+// {"method":"pair","phone":"+15551234567"}.
+type loginWSCommand struct {
+	Method string `json:"method"`
+	Phone  string `json:"phone"`
+}
+
+// handleLoginWS upgrades the request to a WebSocket, reads the caller's
+// chosen pairing method, and streams the full lifecycle (every rotated QR
+// code or a phone pairing code, then success/timeout/error) instead of
+// making the caller poll for a single code.
+func handleLoginWS(um *UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+
+		conn, err := loginWSUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			addLog(fmt.Sprintf("[%s] login/ws upgrade failed: %s", userID, err.Error()))
+			return
+		}
+		defer conn.Close()
+
+		var cmd loginWSCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			conn.WriteJSON(loginWSFrame{Type: "error", Message: "expected a JSON command frame, e.g. {\"method\":\"qr\"}"})
+			return
+		}
+
+		var events <-chan loginEvent
+		var cancel func()
+		switch cmd.Method {
+		case "", "qr":
+			events, cancel, err = um.LoginStream(userID)
+		case "pair":
+			if !isE164(cmd.Phone) {
+				conn.WriteJSON(loginWSFrame{Type: "error", Message: "phone must be in E.164 format, e.g. +15551234567"})
+				return
+			}
+			events, cancel, err = um.PairPhoneStream(userID, cmd.Phone)
+		default:
+			conn.WriteJSON(loginWSFrame{Type: "error", Message: "unknown method: " + cmd.Method})
+			return
+		}
+		if err != nil {
+			conn.WriteJSON(loginWSFrame{Type: "error", Message: err.Error()})
+			return
+		}
+
+		// A closed connection (including a client-initiated close frame)
+		// cancels the pending login instead of leaking the client.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case evt, ok := <-events:
+				if !ok {
+					return
+				}
+				frame := loginWSFrame{Type: evt.Type, Code: evt.Code, Image: evt.Image, JID: evt.JID, Message: evt.Message}
+				if err := conn.WriteJSON(frame); err != nil {
+					cancel()
+					drainLoginEvents(events)
+					return
+				}
+				if evt.Type == "success" || evt.Type == "timeout" || evt.Type == "error" {
+					return
+				}
+			case <-closed:
+				cancel()
+				drainLoginEvents(events)
+				return
+			}
+		}
+	}
+}
+
+// drainLoginEvents reads events to completion in the background. Both
+// LoginStream and PairPhoneStream send on an unbuffered channel and block
+// until it's read, so abandoning events after an early return (a failed
+// WriteJSON or a closed socket) would leak the producer goroutine, and with
+// it the underlying client, for the lifetime of the process.
+func drainLoginEvents(events <-chan loginEvent) {
+	go func() {
+		for range events {
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// pairPhoneTimeout bounds how long a phone-number pairing code stays valid
+// before we give up waiting for the user to enter it on their phone.
+const pairPhoneTimeout = 2 * time.Minute
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// isE164 reports whether phone looks like a valid E.164 number.
+func isE164(phone string) bool {
+	return e164Pattern.MatchString(phone)
+}
+
+// PairPhoneStream starts an unauthenticated connection for userID and
+// requests a WhatsApp "link with phone number" pairing code, as an
+// alternative to scanning a QR code. It streams a "pair" event carrying
+// the code, followed by "success", "timeout", or "error" once the user
+// enters it (or doesn't). The returned cancel func disconnects the
+// pending client if the caller gives up.
+func (um *UserManager) PairPhoneStream(userID, phone string) (<-chan loginEvent, func(), error) {
+	if client, ok := um.GetClient(userID); ok && client.IsLoggedIn() {
+		return nil, nil, fmt.Errorf("user %s is already logged in", userID)
+	}
+
+	device, err := um.deviceFor(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if device.ID != nil {
+		return nil, nil, fmt.Errorf("user %s is already paired; delete the session before pairing again", userID)
+	}
+
+	um.disconnectPendingClient(userID)
+	client := um.newClient(userID, device)
+	cancel := func() { client.Disconnect() }
+
+	if err := client.Connect(); err != nil {
+		return nil, nil, fmt.Errorf("connecting: %w", err)
+	}
+
+	linkingCode, err := client.PairPhone(context.Background(), phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("requesting pairing code: %w", err)
+	}
+
+	result := make(chan loginEvent, 1)
+	var handlerID uint32
+	handlerID = client.AddEventHandler(func(evt interface{}) {
+		switch v := evt.(type) {
+		case *events.PairSuccess:
+			result <- loginEvent{Type: "success", JID: v.ID.String()}
+		case *events.PairError:
+			result <- loginEvent{Type: "error", Message: v.Error.Error()}
+		}
+	})
+
+	out := make(chan loginEvent)
+	go func() {
+		defer close(out)
+		defer client.RemoveEventHandler(handlerID)
+		out <- loginEvent{Type: "pair", Code: linkingCode}
+		select {
+		case evt := <-result:
+			out <- evt
+		case <-time.After(pairPhoneTimeout):
+			out <- loginEvent{Type: "timeout"}
+		}
+	}()
+
+	return out, cancel, nil
+}
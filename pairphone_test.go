@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestIsE164(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  bool
+	}{
+		{"+15551234567", true},
+		{"+442071838750", true},
+		{"15551234567", false}, // missing leading +
+		{"+0123456789", false}, // country code can't start with 0
+		{"+1", false},          // too short
+		{"", false},
+		{"+1555123456789012", false}, // too long
+		{"+1 555 123 4567", false},   // no spaces allowed
+	}
+
+	for _, tt := range tests {
+		if got := isE164(tt.phone); got != tt.want {
+			t.Errorf("isE164(%q) = %v, want %v", tt.phone, got, tt.want)
+		}
+	}
+}
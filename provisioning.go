@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// registerProvisioningRoutes wires the per-user account management
+// endpoints (login, logout, ping, disconnect, reconnect, delete_session)
+// under cfg.ProvisioningPrefix, all guarded by AuthMiddleware.
+func registerProvisioningRoutes(r *mux.Router, cfg *Config, um *UserManager) {
+	sub := r.PathPrefix(cfg.ProvisioningPrefix).Subrouter()
+	sub.Use(AuthMiddleware(cfg))
+
+	sub.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		qr, err := um.Login(userID)
+		if err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]string{"qr": qr})
+	}).Methods("POST")
+
+	// Streams every QR rotation plus the final success/timeout/error over a
+	// WebSocket, so callers don't race a single polled code.
+	sub.HandleFunc("/login/ws", handleLoginWS(um)).Methods("GET")
+
+	sub.HandleFunc("/pair", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+
+		var req struct {
+			Phone string `json:"phone"`
+		}
+		if err := parseJSONBody(r, &req); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !isE164(req.Phone) {
+			respondJSONError(w, http.StatusBadRequest, "phone must be in E.164 format, e.g. +15551234567")
+			return
+		}
+
+		events, _, err := um.PairPhoneStream(userID, req.Phone)
+		if err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		evt, ok := <-events
+		// This endpoint only reports the pairing code; drain the rest of the
+		// stream in the background so the producer goroutine doesn't block.
+		go func() {
+			for range events {
+			}
+		}()
+		if !ok || evt.Type != "pair" {
+			respondJSONError(w, http.StatusInternalServerError, "failed to obtain pairing code")
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"code":       evt.Code,
+			"expires_in": int(pairPhoneTimeout.Seconds()),
+		})
+	}).Methods("POST")
+
+	sub.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		if err := um.Logout(userID); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]bool{"success": true})
+	}).Methods("POST")
+
+	sub.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		respondJSON(w, http.StatusOK, um.Ping(userID))
+	}).Methods("GET")
+
+	// Fine-grained bridge connection state, for orchestrators that need
+	// more than ping's bare logged_in/connected booleans.
+	sub.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		respondJSON(w, http.StatusOK, um.Status(userID))
+	}).Methods("GET")
+
+	sub.HandleFunc("/disconnect", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		if err := um.Disconnect(userID); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]bool{"success": true})
+	}).Methods("POST")
+
+	sub.HandleFunc("/reconnect", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		if _, err := um.Reconnect(userID); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]bool{"success": true})
+	}).Methods("POST")
+
+	sub.HandleFunc("/delete_session", func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		if err := um.DeleteSession(userID); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]bool{"success": true})
+	}).Methods("POST")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// sendRequest is the JSON body accepted by POST /send.
+type sendRequest struct {
+	To      string           `json:"to"`
+	Text    string           `json:"text,omitempty"`
+	Media   *sendMediaParams `json:"media,omitempty"`
+	ReplyTo *sendReplyParams `json:"reply_to,omitempty"`
+}
+
+// sendMediaParams describes an image/video/audio/document attachment.
+// Data is the base64-encoded file contents.
+type sendMediaParams struct {
+	Type     string `json:"type"`
+	Data     string `json:"data"`
+	MimeType string `json:"mime_type"`
+	FileName string `json:"file_name,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+// sendReplyParams quotes an earlier message.
+type sendReplyParams struct {
+	ID          string `json:"id"`
+	Participant string `json:"participant,omitempty"`
+}
+
+type sendResponse struct {
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// handleSend builds and sends a WhatsApp message on behalf of the
+// authenticated user: plain text, an uploaded media attachment, or either
+// one as a quoted reply.
+func handleSend(um *UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		client, ok := um.GetClient(userID)
+		if !ok {
+			respondJSONError(w, http.StatusBadRequest, "user is not logged in")
+			return
+		}
+
+		var req sendRequest
+		if err := parseJSONBody(r, &req); err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		recipient, err := resolveRecipient(client, req.To)
+		if err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		msg, err := buildOutgoingMessage(r.Context(), client, req)
+		if err != nil {
+			respondJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		resp, err := client.SendMessage(r.Context(), recipient, msg)
+		if err != nil {
+			respondJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		respondJSON(w, http.StatusOK, sendResponse{
+			ID:        resp.ID,
+			Timestamp: resp.Timestamp.Unix(),
+		})
+	}
+}
+
+// resolveRecipient accepts either a group JID (ending in @g.us), a full
+// JID, or a bare phone number, which is normalized to <num>@s.whatsapp.net
+// after confirming it's registered on WhatsApp.
+func resolveRecipient(client *whatsmeow.Client, to string) (types.JID, error) {
+	if to == "" {
+		return types.JID{}, fmt.Errorf("recipient (to) is required")
+	}
+	if strings.Contains(to, "@") {
+		return types.ParseJID(to)
+	}
+
+	number := strings.TrimPrefix(strings.TrimSpace(to), "+")
+	results, err := client.IsOnWhatsApp([]string{number})
+	if err != nil {
+		return types.JID{}, fmt.Errorf("checking recipient: %w", err)
+	}
+	if len(results) == 0 || !results[0].IsIn {
+		return types.JID{}, fmt.Errorf("%s is not on WhatsApp", to)
+	}
+	return results[0].JID, nil
+}
+
+// buildOutgoingMessage constructs the waProto.Message for req, uploading
+// any attached media first.
+func buildOutgoingMessage(ctx context.Context, client *whatsmeow.Client, req sendRequest) (*waProto.Message, error) {
+	var contextInfo *waProto.ContextInfo
+	if req.ReplyTo != nil && req.ReplyTo.ID != "" {
+		contextInfo = &waProto.ContextInfo{
+			StanzaId: proto.String(req.ReplyTo.ID),
+		}
+		if req.ReplyTo.Participant != "" {
+			contextInfo.Participant = proto.String(req.ReplyTo.Participant)
+		}
+	}
+
+	if req.Media == nil {
+		if req.Text == "" {
+			return nil, fmt.Errorf("text or media is required")
+		}
+		if contextInfo == nil {
+			return &waProto.Message{Conversation: proto.String(req.Text)}, nil
+		}
+		return &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String(req.Text),
+				ContextInfo: contextInfo,
+			},
+		}, nil
+	}
+
+	return buildMediaMessage(ctx, client, *req.Media, contextInfo)
+}
+
+func buildMediaMessage(ctx context.Context, client *whatsmeow.Client, media sendMediaParams, contextInfo *waProto.ContextInfo) (*waProto.Message, error) {
+	data, err := base64.StdEncoding.DecodeString(media.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding media data: %w", err)
+	}
+
+	mediaType, ok := map[string]whatsmeow.MediaType{
+		"image":    whatsmeow.MediaImage,
+		"video":    whatsmeow.MediaVideo,
+		"audio":    whatsmeow.MediaAudio,
+		"document": whatsmeow.MediaDocument,
+	}[media.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown media type: %s", media.Type)
+	}
+
+	uploaded, err := client.Upload(ctx, data, mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("uploading media: %w", err)
+	}
+
+	switch media.Type {
+	case "image":
+		return &waProto.Message{ImageMessage: &waProto.ImageMessage{
+			Caption:       proto.String(media.Caption),
+			Mimetype:      proto.String(media.MimeType),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			ContextInfo:   contextInfo,
+		}}, nil
+	case "video":
+		return &waProto.Message{VideoMessage: &waProto.VideoMessage{
+			Caption:       proto.String(media.Caption),
+			Mimetype:      proto.String(media.MimeType),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			ContextInfo:   contextInfo,
+		}}, nil
+	case "audio":
+		return &waProto.Message{AudioMessage: &waProto.AudioMessage{
+			Mimetype:      proto.String(media.MimeType),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			ContextInfo:   contextInfo,
+		}}, nil
+	case "document":
+		return &waProto.Message{DocumentMessage: &waProto.DocumentMessage{
+			Caption:       proto.String(media.Caption),
+			Title:         proto.String(media.FileName),
+			FileName:      proto.String(media.FileName),
+			Mimetype:      proto.String(media.MimeType),
+			Url:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSha256: uploaded.FileEncSHA256,
+			FileSha256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			ContextInfo:   contextInfo,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown media type: %s", media.Type)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+// TestResolveRecipientJID covers the paths of resolveRecipient that don't
+// touch the whatsmeow client: a bare recipient is rejected up front, and a
+// JID-shaped recipient (containing "@") is parsed directly instead of going
+// through the IsOnWhatsApp lookup used for phone numbers.
+func TestResolveRecipientJID(t *testing.T) {
+	if _, err := resolveRecipient(nil, ""); err == nil {
+		t.Error("resolveRecipient(\"\") should require a recipient")
+	}
+
+	jid, err := resolveRecipient(nil, "1234567890@s.whatsapp.net")
+	if err != nil {
+		t.Fatalf("resolveRecipient(user JID) returned error: %v", err)
+	}
+	if jid.User != "1234567890" || jid.Server != "s.whatsapp.net" {
+		t.Errorf("resolveRecipient(user JID) = %+v, want user=1234567890 server=s.whatsapp.net", jid)
+	}
+
+	groupJID, err := resolveRecipient(nil, "123456789-987654321@g.us")
+	if err != nil {
+		t.Fatalf("resolveRecipient(group JID) returned error: %v", err)
+	}
+	if groupJID.Server != "g.us" {
+		t.Errorf("resolveRecipient(group JID).Server = %q, want g.us", groupJID.Server)
+	}
+}
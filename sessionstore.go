@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// SessionStore persists the mapping between the operator-chosen user IDs
+// used by the provisioning API and the WhatsApp JIDs they end up paired
+// with, so restarts can re-attach existing sqlstore devices to the right
+// user without re-scanning a QR code.
+type SessionStore struct {
+	path string
+
+	mu   sync.Mutex
+	jids map[string]string // userID -> JID string
+}
+
+func NewSessionStore(path string) *SessionStore {
+	s := &SessionStore{path: path, jids: make(map[string]string)}
+	s.load()
+	return s
+}
+
+func (s *SessionStore) load() {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &s.jids)
+}
+
+func (s *SessionStore) save() {
+	data, err := json.MarshalIndent(s.jids, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(s.path, data, 0o600)
+}
+
+// Get returns the JID string stored for userID, if any.
+func (s *SessionStore) Get(userID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jid, ok := s.jids[userID]
+	return jid, ok
+}
+
+// Set records that userID is paired as jid and persists the change.
+func (s *SessionStore) Set(userID, jid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jids[userID] = jid
+	s.save()
+}
+
+// Delete removes any stored JID for userID and persists the change.
+func (s *SessionStore) Delete(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jids, userID)
+	s.save()
+}
+
+// All returns a copy of the full userID -> JID mapping.
+func (s *SessionStore) All() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]string, len(s.jids))
+	for k, v := range s.jids {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// StateEvent is one of the mautrix-whatsapp-style bridge connection
+// states surfaced by GET /_provision/v1/status.
+type StateEvent string
+
+const (
+	StateRunning             StateEvent = "RUNNING"
+	StateConnecting          StateEvent = "CONNECTING"
+	StateConnected           StateEvent = "CONNECTED"
+	StateTransientDisconnect StateEvent = "TRANSIENT_DISCONNECT"
+	StateBadCredentials      StateEvent = "BAD_CREDENTIALS"
+	StateLoggedOut           StateEvent = "LOGGED_OUT"
+	StateUnknownError        StateEvent = "UNKNOWN_ERROR"
+)
+
+// BridgeState is the structured connection status for one user's session,
+// giving orchestrators (systemd, k8s liveness, uptime monitors) more than
+// a bare connected/disconnected string.
+type BridgeState struct {
+	StateEvent StateEvent `json:"state_event"`
+	RemoteID   string     `json:"remote_id,omitempty"`
+	RemoteName string     `json:"remote_name,omitempty"`
+	Timestamp  int64      `json:"timestamp"`
+	Reason     string     `json:"reason,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// stateTracker remembers the last state transition reported by each
+// user's event handler, so Status can report why a session is down
+// rather than just whether it's connected right now.
+type stateTracker struct {
+	mu     sync.Mutex
+	states map[string]BridgeState
+}
+
+func newStateTracker() *stateTracker {
+	return &stateTracker{states: make(map[string]BridgeState)}
+}
+
+func (t *stateTracker) set(userID string, state StateEvent, reason, errCode string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.states[userID] = BridgeState{
+		StateEvent: state,
+		Timestamp:  time.Now().Unix(),
+		Reason:     reason,
+		Error:      errCode,
+	}
+}
+
+func (t *stateTracker) get(userID string) (BridgeState, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.states[userID]
+	return s, ok
+}
+
+// Status reports the structured bridge connection state for userID,
+// combining the live whatsmeow client state with the last transition
+// recorded by the event handler.
+func (um *UserManager) Status(userID string) BridgeState {
+	state, _ := um.states.get(userID)
+	state.Timestamp = time.Now().Unix()
+
+	client, ok := um.GetClient(userID)
+	if !ok {
+		if state.StateEvent == "" {
+			state.StateEvent = StateLoggedOut
+		}
+		return state
+	}
+
+	if client.Store.ID != nil {
+		state.RemoteID = client.Store.ID.String()
+	}
+	if client.Store.PushName != "" {
+		state.RemoteName = client.Store.PushName
+	}
+
+	switch {
+	case client.IsLoggedIn() && client.IsConnected():
+		state.StateEvent = StateConnected
+		state.Reason = ""
+		state.Error = ""
+	case client.IsConnected():
+		state.StateEvent = StateConnecting
+	case state.StateEvent == "":
+		state.StateEvent = StateConnecting
+	}
+
+	return state
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	qrcode "github.com/skip2/go-qrcode"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// UserManager keeps one *whatsmeow.Client per provisioned user, replacing
+// the single global client variable with a registry that lets the bridge
+// serve many WhatsApp accounts at once. Sessions are backed by the shared
+// sqlstore.Container (one sqlite device row per paired user) and by a
+// SessionStore that remembers which JID belongs to which user ID across
+// restarts.
+type UserManager struct {
+	container *sqlstore.Container
+	sessions  *SessionStore
+	webhooks  *WebhookDispatcher
+	states    *stateTracker
+	history   *HistoryStore
+	log       waLog.Logger
+
+	mu      sync.RWMutex
+	clients map[string]*whatsmeow.Client
+}
+
+func NewUserManager(container *sqlstore.Container, sessions *SessionStore, webhooks *WebhookDispatcher, history *HistoryStore, log waLog.Logger) *UserManager {
+	return &UserManager{
+		container: container,
+		sessions:  sessions,
+		webhooks:  webhooks,
+		states:    newStateTracker(),
+		history:   history,
+		log:       log,
+		clients:   make(map[string]*whatsmeow.Client),
+	}
+}
+
+// LoadExisting reconnects a client for every user ID that SessionStore
+// already associates with a paired device. It's called once at startup.
+func (um *UserManager) LoadExisting() {
+	for userID, jid := range um.sessions.All() {
+		if _, err := um.Reconnect(userID); err != nil {
+			addLog(fmt.Sprintf("Failed to restore session for %s (%s): %s", userID, jid, err.Error()))
+		}
+	}
+}
+
+// GetClient returns the live client for userID, if one has been created.
+func (um *UserManager) GetClient(userID string) (*whatsmeow.Client, bool) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+	c, ok := um.clients[userID]
+	return c, ok
+}
+
+func (um *UserManager) setClient(userID string, client *whatsmeow.Client) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	um.clients[userID] = client
+}
+
+func (um *UserManager) removeClient(userID string) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	delete(um.clients, userID)
+}
+
+// disconnectPendingClient tears down any client already registered for
+// userID that hasn't finished pairing yet. Without this, a second
+// concurrent /login or /pair call for the same user would have newClient
+// silently overwrite the map entry, orphaning the first client's websocket
+// and QR-draining goroutine with nothing left to cancel it.
+func (um *UserManager) disconnectPendingClient(userID string) {
+	if client, ok := um.GetClient(userID); ok && !client.IsLoggedIn() {
+		client.Disconnect()
+	}
+}
+
+// newClient builds a whatsmeow client for userID around device and wires
+// up the shared event handler.
+func (um *UserManager) newClient(userID string, device *store.Device) *whatsmeow.Client {
+	clientLog := waLog.Stdout("Client/"+userID, "WARN", true)
+	client := whatsmeow.NewClient(device, clientLog)
+	client.AddEventHandler(func(evt interface{}) {
+		um.handleEvent(userID, client, evt)
+	})
+	um.setClient(userID, client)
+	return client
+}
+
+// deviceFor returns the store.Device for userID, creating a brand new one
+// if the user has never paired before.
+func (um *UserManager) deviceFor(userID string) (*store.Device, error) {
+	if jidStr, ok := um.sessions.Get(userID); ok {
+		jid, err := types.ParseJID(jidStr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing stored JID for %s: %w", userID, err)
+		}
+		device, err := um.container.GetDevice(jid)
+		if err != nil {
+			return nil, fmt.Errorf("loading device for %s: %w", userID, err)
+		}
+		if device != nil {
+			return device, nil
+		}
+	}
+	return um.container.NewDevice(), nil
+}
+
+// loginEvent is one frame of the pairing lifecycle: a rotated QR code, a
+// successful pairing, a timeout, or an error. It's streamed live to
+// /login/ws and also used internally by the blocking Login helper below.
+type loginEvent struct {
+	Type    string // "qr", "success", "timeout", "error"
+	Code    string
+	Image   string
+	JID     string
+	Message string
+}
+
+// LoginStream starts (or resumes) the connection for userID and streams
+// every pairing lifecycle event on the returned channel: a "qr" event for
+// each rotated code, "success" once paired, "timeout" if the pairing
+// window closes without success, and "error" on failure. The returned
+// cancel func disconnects the pending client if the caller gives up.
+func (um *UserManager) LoginStream(userID string) (<-chan loginEvent, func(), error) {
+	if client, ok := um.GetClient(userID); ok && client.IsLoggedIn() {
+		return nil, nil, fmt.Errorf("user %s is already logged in", userID)
+	}
+
+	device, err := um.deviceFor(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	um.disconnectPendingClient(userID)
+	client := um.newClient(userID, device)
+	cancel := func() { client.Disconnect() }
+
+	if client.Store.ID != nil {
+		out := make(chan loginEvent, 1)
+		go func() {
+			defer close(out)
+			if err := client.Connect(); err != nil {
+				out <- loginEvent{Type: "error", Message: err.Error()}
+				return
+			}
+			out <- loginEvent{Type: "success", JID: client.Store.ID.String()}
+		}()
+		return out, cancel, nil
+	}
+
+	qrChan, err := client.GetQRChannel(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting QR channel: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return nil, nil, fmt.Errorf("connecting: %w", err)
+	}
+
+	out := make(chan loginEvent)
+	go func() {
+		defer close(out)
+		for evt := range qrChan {
+			switch evt.Event {
+			case "code":
+				png, err := qrcode.Encode(evt.Code, qrcode.Medium, 256)
+				if err != nil {
+					out <- loginEvent{Type: "error", Message: err.Error()}
+					continue
+				}
+				out <- loginEvent{
+					Type:  "qr",
+					Code:  evt.Code,
+					Image: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+				}
+			case "success":
+				jid := ""
+				if client.Store.ID != nil {
+					jid = client.Store.ID.String()
+				}
+				out <- loginEvent{Type: "success", JID: jid}
+			case "timeout":
+				out <- loginEvent{Type: "timeout"}
+			default:
+				if evt.Error != nil {
+					out <- loginEvent{Type: "error", Message: evt.Error.Error()}
+				} else {
+					out <- loginEvent{Type: "error", Message: evt.Event}
+				}
+			}
+		}
+	}()
+
+	return out, cancel, nil
+}
+
+// Login starts (or resumes) the connection for userID and blocks until the
+// first QR code, a success, or an error is available. It exists for
+// callers that just want a single code rather than the full rotation
+// stream that /login/ws offers.
+func (um *UserManager) Login(userID string) (qr string, err error) {
+	events, cancel, err := um.LoginStream(userID)
+	if err != nil {
+		return "", err
+	}
+
+	evt, ok := <-events
+	if !ok {
+		return "", fmt.Errorf("login stream closed unexpectedly")
+	}
+	// Login only reports the first event; drain the rest in the background
+	// so later QR rotations don't block the producer goroutine forever.
+	go func() {
+		for range events {
+		}
+	}()
+	switch evt.Type {
+	case "qr":
+		return evt.Image, nil
+	case "success":
+		return "", nil
+	case "timeout":
+		cancel()
+		return "", fmt.Errorf("pairing window timed out")
+	default:
+		return "", fmt.Errorf("%s", evt.Message)
+	}
+}
+
+// Logout ends the WhatsApp session for userID and forgets its device.
+func (um *UserManager) Logout(userID string) error {
+	client, ok := um.GetClient(userID)
+	if !ok {
+		return fmt.Errorf("user %s has no active session", userID)
+	}
+	err := client.Logout()
+	um.removeClient(userID)
+	um.sessions.Delete(userID)
+	um.states.set(userID, StateLoggedOut, "", "")
+	return err
+}
+
+// Disconnect drops the websocket connection for userID without forgetting
+// the paired device, so Reconnect can resume it later.
+func (um *UserManager) Disconnect(userID string) error {
+	client, ok := um.GetClient(userID)
+	if !ok {
+		return fmt.Errorf("user %s has no active session", userID)
+	}
+	client.Disconnect()
+	return nil
+}
+
+// Reconnect re-establishes the connection for a previously paired userID,
+// recreating the client if it isn't currently held in memory.
+func (um *UserManager) Reconnect(userID string) (*whatsmeow.Client, error) {
+	if client, ok := um.GetClient(userID); ok {
+		return client, client.Connect()
+	}
+
+	device, err := um.deviceFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if device.ID == nil {
+		return nil, fmt.Errorf("user %s has not paired yet", userID)
+	}
+	client := um.newClient(userID, device)
+	return client, client.Connect()
+}
+
+// DeleteSession disconnects userID, if connected, and permanently removes
+// its device from the sqlite store.
+func (um *UserManager) DeleteSession(userID string) error {
+	device, err := um.deviceFor(userID)
+	if err != nil {
+		return err
+	}
+
+	if client, ok := um.GetClient(userID); ok {
+		client.Disconnect()
+		um.removeClient(userID)
+	}
+
+	if device.ID != nil {
+		if err := um.container.DeleteDevice(device); err != nil {
+			return fmt.Errorf("deleting device: %w", err)
+		}
+	}
+	um.sessions.Delete(userID)
+	um.states.set(userID, StateLoggedOut, "", "")
+	return nil
+}
+
+// PingResult describes the current connection state of one user's session.
+type PingResult struct {
+	LoggedIn  bool   `json:"logged_in"`
+	Connected bool   `json:"connected"`
+	JID       string `json:"jid,omitempty"`
+}
+
+// Ping reports the live connection state for userID.
+func (um *UserManager) Ping(userID string) PingResult {
+	client, ok := um.GetClient(userID)
+	if !ok {
+		return PingResult{}
+	}
+	result := PingResult{
+		LoggedIn:  client.IsLoggedIn(),
+		Connected: client.IsConnected(),
+	}
+	if client.Store.ID != nil {
+		result.JID = client.Store.ID.String()
+	}
+	return result
+}
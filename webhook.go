@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookEvent is the payload POSTed to every subscriber whose event
+// filter matches Type.
+type WebhookEvent struct {
+	Type      string          `json:"type"`
+	UserID    string          `json:"user_id"`
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 5 * time.Second
+	webhookMaxBackoff   = 10 * time.Minute
+	webhookPollInterval = 2 * time.Second
+
+	// webhookEnqueueRetries bounds how many times Dispatch retries a queue
+	// insert that fails with a transient sqlite error (e.g. SQLITE_BUSY from
+	// concurrent event-handler goroutines) before giving up on that event.
+	webhookEnqueueRetries = 3
+	webhookEnqueueBackoff = 50 * time.Millisecond
+)
+
+// WebhookDispatcher fans inbound WhatsApp events out to the configured
+// subscriber URLs. Deliveries are buffered in a sqlite queue table so an
+// event isn't lost while a subscriber is unreachable, and are retried with
+// exponential backoff until they succeed or exhaust their attempts.
+type WebhookDispatcher struct {
+	subscribers []WebhookConfig
+	db          *sql.DB
+	httpClient  *http.Client
+}
+
+// NewWebhookDispatcher opens (creating if necessary) the sqlite-backed
+// delivery queue at dbPath for the given subscribers.
+func NewWebhookDispatcher(subscribers []WebhookConfig, dbPath string) (*WebhookDispatcher, error) {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("opening webhook queue: %w", err)
+	}
+	// The queue is written from every user's event-handler goroutine plus
+	// Run's poll loop; serialize on a single connection so sqlite's busy
+	// timeout (rather than a second connection racing for the lock) is what
+	// absorbs contention.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS webhook_queue (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscriber_url TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("creating webhook queue table: %w", err)
+	}
+
+	return &WebhookDispatcher{
+		subscribers: subscribers,
+		db:          db,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Dispatch enqueues evt for every subscriber whose event filter matches.
+func (d *WebhookDispatcher) Dispatch(evt WebhookEvent) {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		addLog(fmt.Sprintf("Failed to marshal webhook event: %s", err.Error()))
+		return
+	}
+
+	for _, sub := range d.subscribers {
+		if !subscriberWants(sub, evt.Type) {
+			continue
+		}
+		if err := d.enqueue(sub.URL, payload); err != nil {
+			addLog(fmt.Sprintf("Failed to queue webhook for %s after %d attempts: %s", sub.URL, webhookEnqueueRetries, err.Error()))
+		}
+	}
+}
+
+// enqueue inserts a queue row, retrying a few times on a transient sqlite
+// error (e.g. SQLITE_BUSY under concurrent writers) before giving up. Losing
+// an event here would defeat the whole point of buffering to disk.
+func (d *WebhookDispatcher) enqueue(url string, payload []byte) error {
+	var err error
+	for attempt := 0; attempt < webhookEnqueueRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookEnqueueBackoff * time.Duration(attempt))
+		}
+		_, err = d.db.Exec(
+			`INSERT INTO webhook_queue (subscriber_url, payload, next_attempt_at) VALUES (?, ?, ?)`,
+			url, payload, time.Now().Unix(),
+		)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func subscriberWants(sub WebhookConfig, eventType string) bool {
+	if len(sub.Events) == 0 {
+		return true
+	}
+	for _, t := range sub.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Run polls the delivery queue and attempts pending deliveries until ctx
+// is cancelled. It's meant to be run in its own goroutine.
+func (d *WebhookDispatcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.deliverPending()
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliverPending() {
+	rows, err := d.db.Query(
+		`SELECT id, subscriber_url, payload, attempts FROM webhook_queue WHERE next_attempt_at <= ? ORDER BY id LIMIT 50`,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		addLog(fmt.Sprintf("Failed to read webhook queue: %s", err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	type job struct {
+		id       int64
+		url      string
+		payload  []byte
+		attempts int
+	}
+	var jobs []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.id, &j.url, &j.payload, &j.attempts); err != nil {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+
+	for _, j := range jobs {
+		d.attemptDelivery(j.id, j.url, j.payload, j.attempts)
+	}
+}
+
+func (d *WebhookDispatcher) attemptDelivery(id int64, url string, payload []byte, attempts int) {
+	secret := d.secretFor(url)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		d.dropJob(id, fmt.Sprintf("building request: %s", err.Error()))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set("X-Webhook-Signature", signPayload(secret, payload))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		d.retryJob(id, attempts, fmt.Sprintf("delivering to %s: %s", url, err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		d.completeJob(id)
+	case resp.StatusCode >= 500:
+		d.retryJob(id, attempts, fmt.Sprintf("%s returned %d", url, resp.StatusCode))
+	default:
+		// A 4xx means the subscriber rejected the payload outright; retrying
+		// the same bytes won't help, so drop it instead of retrying forever.
+		d.dropJob(id, fmt.Sprintf("%s returned %d, not retrying", url, resp.StatusCode))
+	}
+}
+
+func (d *WebhookDispatcher) secretFor(url string) string {
+	for _, sub := range d.subscribers {
+		if sub.URL == url {
+			return sub.Secret
+		}
+	}
+	return ""
+}
+
+func (d *WebhookDispatcher) completeJob(id int64) {
+	if _, err := d.db.Exec(`DELETE FROM webhook_queue WHERE id = ?`, id); err != nil {
+		addLog(fmt.Sprintf("Failed to remove delivered webhook job %d: %s", id, err.Error()))
+	}
+}
+
+func (d *WebhookDispatcher) retryJob(id int64, attempts int, reason string) {
+	attempts++
+	if attempts >= webhookMaxAttempts {
+		addLog(fmt.Sprintf("Webhook job %d giving up after %d attempts: %s", id, attempts, reason))
+		d.dropJob(id, reason)
+		return
+	}
+
+	addLog(fmt.Sprintf("Webhook job %d failed (%s), retrying (attempt %d)", id, reason, attempts))
+	nextAttempt := time.Now().Add(backoffDuration(attempts)).Unix()
+	_, err := d.db.Exec(
+		`UPDATE webhook_queue SET attempts = ?, next_attempt_at = ? WHERE id = ?`,
+		attempts, nextAttempt, id,
+	)
+	if err != nil {
+		addLog(fmt.Sprintf("Failed to reschedule webhook job %d: %s", id, err.Error()))
+	}
+}
+
+func (d *WebhookDispatcher) dropJob(id int64, reason string) {
+	addLog(fmt.Sprintf("Dropping webhook job %d: %s", id, reason))
+	d.completeJob(id)
+}
+
+// backoffDuration returns an exponential backoff delay for the given
+// attempt count, capped at webhookMaxBackoff.
+func backoffDuration(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	return backoff
+}
+
+// signPayload returns the hex-encoded HMAC-SHA256 of payload using secret,
+// for subscribers to verify in the X-Webhook-Signature header.
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestBackoffDuration(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     string
+	}{
+		{0, "5s"},
+		{1, "5s"},
+		{2, "10s"},
+		{3, "20s"},
+		{7, "5m20s"},
+		{100, webhookMaxBackoff.String()}, // caps out instead of overflowing
+	}
+
+	for _, tt := range tests {
+		if got := backoffDuration(tt.attempts).String(); got != tt.want {
+			t.Errorf("backoffDuration(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSubscriberWants(t *testing.T) {
+	all := WebhookConfig{URL: "https://example.com/all"}
+	filtered := WebhookConfig{URL: "https://example.com/filtered", Events: []string{"message", "receipt"}}
+
+	if !subscriberWants(all, "presence") {
+		t.Error("a subscriber with no event filter should receive every event type")
+	}
+	if !subscriberWants(filtered, "message") {
+		t.Error("filtered subscriber should receive a listed event type")
+	}
+	if subscriberWants(filtered, "presence") {
+		t.Error("filtered subscriber should not receive an unlisted event type")
+	}
+}